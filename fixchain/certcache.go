@@ -0,0 +1,124 @@
+package fixchain
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// MemCertCache is an in-memory CertCache. With a zero TTL, entries never
+// expire; this is what NewFixer uses by default. Operators who want fetched
+// intermediates to eventually be re-fetched, e.g. because a CA sometimes
+// rotates which certificate an AIA URL serves, can construct one with
+// NewMemCertCache and a positive TTL and pass it to NewFixerWithCache.
+type MemCertCache struct {
+	ttl time.Duration
+
+	mu sync.Mutex
+	m  map[string]memCertEntry
+}
+
+type memCertEntry struct {
+	der     []byte
+	expires time.Time
+}
+
+// NewMemCertCache returns a MemCertCache whose entries expire after ttl, or
+// never if ttl is zero.
+func NewMemCertCache(ttl time.Duration) *MemCertCache {
+	return &MemCertCache{ttl: ttl, m: make(map[string]memCertEntry)}
+}
+
+// Get implements CertCache.
+func (c *MemCertCache) Get(url string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.m[url]
+	if !ok {
+		return nil, false
+	}
+	if c.ttl > 0 && time.Now().After(e.expires) {
+		delete(c.m, url)
+		return nil, false
+	}
+	return e.der, true
+}
+
+// Put implements CertCache.
+func (c *MemCertCache) Put(url string, der []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := memCertEntry{der: der}
+	if c.ttl > 0 {
+		e.expires = time.Now().Add(c.ttl)
+	}
+	c.m[url] = e
+}
+
+// fetchCoalescer is implemented by CertCache backends that want to
+// coalesce concurrent callers' entire "check cache, fetch on miss, store"
+// sequence for a given URL, rather than just the individual Get/Put calls.
+// urlCache uses it, when the backing cache provides it, instead of calling
+// Get/Put around the fetch itself.
+type fetchCoalescer interface {
+	// resolve returns the cached result for url if one exists, or else
+	// calls fetch exactly once on behalf of every concurrent caller
+	// resolving the same url, storing its result before returning it.
+	resolve(url string, fetch func() ([]byte, error)) ([]byte, error)
+}
+
+// SingleflightCertCache wraps another CertCache so that concurrent callers
+// resolving the same URL, e.g. two fixers sharing one backing cache, share
+// a single backing-cache lookup and, on a miss, a single fetch: only the
+// first caller actually fetches and stores the result, and every other
+// caller waiting on that URL receives the same outcome instead of each
+// issuing its own HTTP request.
+type SingleflightCertCache struct {
+	backing CertCache
+	group   singleflight.Group
+}
+
+// NewSingleflightCertCache wraps backing with fetch coalescing.
+func NewSingleflightCertCache(backing CertCache) *SingleflightCertCache {
+	return &SingleflightCertCache{backing: backing}
+}
+
+// Get implements CertCache by delegating to the backing cache.
+func (c *SingleflightCertCache) Get(url string) ([]byte, bool) {
+	return c.backing.Get(url)
+}
+
+// Put implements CertCache by delegating to the backing cache.
+func (c *SingleflightCertCache) Put(url string, der []byte) {
+	c.backing.Put(url, der)
+}
+
+// resolve implements fetchCoalescer: it checks the backing cache and, on a
+// miss, calls fetch and stores the outcome, sharing all of that work among
+// concurrent callers resolving the same url.
+func (c *SingleflightCertCache) resolve(url string, fetch func() ([]byte, error)) ([]byte, error) {
+	v, err, _ := c.group.Do(url, func() (interface{}, error) {
+		if der, found := c.backing.Get(url); found {
+			if der == nil {
+				return nil, fmt.Errorf("fixchain: cached negative result for %q", url)
+			}
+			return der, nil
+		}
+
+		der, err := fetch()
+		if err == nil {
+			c.backing.Put(url, der)
+		} else {
+			c.backing.Put(url, nil)
+		}
+		return der, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
@@ -0,0 +1,81 @@
+package fixchain
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemCertCacheTTLExpiry(t *testing.T) {
+	c := NewMemCertCache(10 * time.Millisecond)
+	c.Put("url", []byte("der"))
+
+	if der, ok := c.Get("url"); !ok || !bytes.Equal(der, []byte("der")) {
+		t.Fatalf("Get immediately after Put = (%v, %v), want ([]byte(\"der\"), true)", der, ok)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if der, ok := c.Get("url"); ok {
+		t.Fatalf("Get after TTL expiry = (%v, %v), want (nil, false)", der, ok)
+	}
+}
+
+func TestDiskCacheRoundTrip(t *testing.T) {
+	c := NewDiskCache(t.TempDir())
+
+	c.Put("url", []byte("der-bytes"))
+	der, ok := c.Get("url")
+	if !ok || !bytes.Equal(der, []byte("der-bytes")) {
+		t.Fatalf("Get after Put = (%v, %v), want ([]byte(\"der-bytes\"), true)", der, ok)
+	}
+
+	if _, ok := c.Get("missing"); ok {
+		t.Fatal("Get of a url never Put returned ok=true")
+	}
+
+	c.Put("negative", nil)
+	if der, ok := c.Get("negative"); ok {
+		t.Fatalf("Get after Put(nil) = (%v, %v), want (nil, false): negative results are not persisted to disk", der, ok)
+	}
+}
+
+// TestSingleflightCertCacheCoalescesFetch checks that concurrent callers
+// resolving the same URL share a single fetch.
+func TestSingleflightCertCacheCoalescesFetch(t *testing.T) {
+	c := NewSingleflightCertCache(NewMemCertCache(0))
+
+	var calls atomic.Int32
+	fetch := func() ([]byte, error) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return []byte("der-bytes"), nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	errs := make([]error, callers)
+	ders := make([][]byte, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ders[i], errs[i] = c.resolve("url", fetch)
+		}(i)
+	}
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("fetch was called %d times, want 1", got)
+	}
+	for i := range errs {
+		if errs[i] != nil {
+			t.Fatalf("resolve() caller %d: %v", i, errs[i])
+		}
+		if !bytes.Equal(ders[i], []byte("der-bytes")) {
+			t.Fatalf("resolve() caller %d = %q, want %q", i, ders[i], "der-bytes")
+		}
+	}
+}
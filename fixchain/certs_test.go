@@ -0,0 +1,103 @@
+package fixchain
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509/pkix"
+)
+
+// testChain is a minimal root -> intermediate -> leaf chain used across
+// fixchain's tests.
+type testChain struct {
+	rootCert *x509.Certificate
+	intCert  *x509.Certificate
+	leafCert *x509.Certificate
+}
+
+// newTestChain builds a fresh, currently-valid root/intermediate/leaf chain.
+// If issuingCertificateURL is non-empty, it is recorded on the leaf's AIA
+// extension so handleChain has something to fetch.
+func newTestChain(t *testing.T, issuingCertificateURL string) *testChain {
+	t.Helper()
+
+	rootKey := mustGenerateKey(t)
+	root := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:               time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, root, root, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatalf("creating root cert: %v", err)
+	}
+	rootCert, err := x509.ParseCertificate(rootDER)
+	if err != nil {
+		t.Fatalf("parsing root cert: %v", err)
+	}
+
+	intKey := mustGenerateKey(t)
+	intTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "intermediate"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	intDER, err := x509.CreateCertificate(rand.Reader, intTmpl, rootCert, &intKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatalf("creating intermediate cert: %v", err)
+	}
+	intCert, err := x509.ParseCertificate(intDER)
+	if err != nil {
+		t.Fatalf("parsing intermediate cert: %v", err)
+	}
+
+	leafKey := mustGenerateKey(t)
+	leafTmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "leaf"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if issuingCertificateURL != "" {
+		leafTmpl.IssuingCertificateURL = []string{issuingCertificateURL}
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTmpl, intCert, &leafKey.PublicKey, intKey)
+	if err != nil {
+		t.Fatalf("creating leaf cert: %v", err)
+	}
+	leafCert, err := x509.ParseCertificate(leafDER)
+	if err != nil {
+		t.Fatalf("parsing leaf cert: %v", err)
+	}
+
+	return &testChain{rootCert: rootCert, intCert: intCert, leafCert: leafCert}
+}
+
+func (tc *testChain) roots() *x509.CertPool {
+	p := x509.NewCertPool()
+	p.AddCert(tc.rootCert)
+	return p
+}
+
+func mustGenerateKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	return k
+}
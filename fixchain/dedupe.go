@@ -0,0 +1,37 @@
+package fixchain
+
+import (
+	"bytes"
+
+	"github.com/google/certificate-transparency-go/x509"
+)
+
+// DedupedChain is a certificate chain with any duplicate certificates
+// (by DER bytes) removed.
+type DedupedChain struct {
+	certs []*x509.Certificate
+}
+
+// NewDedupedChain builds a DedupedChain from certs, dropping any entry whose
+// raw bytes match one already kept.
+func NewDedupedChain(certs []*x509.Certificate) *DedupedChain {
+	d := &DedupedChain{}
+	for _, c := range certs {
+		dup := false
+		for _, kept := range d.certs {
+			if bytes.Equal(kept.Raw, c.Raw) {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			d.certs = append(d.certs, c)
+		}
+	}
+	return d
+}
+
+// Certs returns the deduplicated certificates in the chain.
+func (d *DedupedChain) Certs() []*x509.Certificate {
+	return d.certs
+}
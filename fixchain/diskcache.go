@@ -0,0 +1,69 @@
+package fixchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// DiskCache is a CertCache backed by files on disk, one per URL, named by
+// the hex-encoded SHA-256 of the URL. It has no TTL: an AIA-issuer URL is
+// assumed to keep serving the same certificate, so once fetched it is kept
+// until the cache directory is cleared out by hand. Negative results are
+// not persisted, so a CA that was briefly unreachable is retried on the
+// next process run rather than poisoning the cache indefinitely.
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache returns a DiskCache storing files under dir, which must
+// already exist and be writable.
+func NewDiskCache(dir string) *DiskCache {
+	return &DiskCache{dir: dir}
+}
+
+func (c *DiskCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// Get implements CertCache.
+func (c *DiskCache) Get(url string) ([]byte, bool) {
+	der, err := ioutil.ReadFile(c.path(url))
+	if err != nil {
+		return nil, false
+	}
+	return der, true
+}
+
+// Put implements CertCache, writing der to disk via a temp file and
+// rename so that a reader never observes a partially written entry.
+// Negative results (der == nil) are dropped, per the type doc comment.
+func (c *DiskCache) Put(url string, der []byte) {
+	if der == nil {
+		return
+	}
+
+	tmp, err := ioutil.TempFile(c.dir, "tmp-fixchain-cache-")
+	if err != nil {
+		log.Printf("fixchain: DiskCache: creating temp file for %q: %v", url, err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(der); err != nil {
+		tmp.Close()
+		log.Printf("fixchain: DiskCache: writing %q: %v", url, err)
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		log.Printf("fixchain: DiskCache: closing temp file for %q: %v", url, err)
+		return
+	}
+	if err := os.Rename(tmp.Name(), c.path(url)); err != nil {
+		log.Printf("fixchain: DiskCache: renaming temp file for %q: %v", url, err)
+	}
+}
@@ -0,0 +1,54 @@
+package fixchain
+
+import "github.com/google/certificate-transparency-go/x509"
+
+// ErrorType categorises the different ways fixing a chain can fail.
+type ErrorType int
+
+const (
+	// None indicates no error occurred.
+	None ErrorType = iota
+	// VerifyFailed indicates that final chain verification failed.
+	VerifyFailed
+	// ParseFailure indicates that a fetched intermediate could not be parsed.
+	ParseFailure
+	// FixFailed indicates that no valid chain could be reconstructed.
+	FixFailed
+	// CannotFetchURL indicates that fetching an intermediate over HTTP failed.
+	CannotFetchURL
+)
+
+// String returns a short, stable label for t suitable for use as a metric
+// label value.
+func (t ErrorType) String() string {
+	switch t {
+	case VerifyFailed:
+		return "verify_failed"
+	case ParseFailure:
+		return "parse_failure"
+	case FixFailed:
+		return "fix_failed"
+	case CannotFetchURL:
+		return "cannot_fetch_url"
+	default:
+		return "unknown"
+	}
+}
+
+// FixError describes a failure encountered while trying to fix a
+// certificate chain, together with enough context to diagnose it.
+type FixError struct {
+	Type  ErrorType
+	Cert  *x509.Certificate
+	Chain *DedupedChain
+	URL   string
+	Err   error
+}
+
+// Error implements the error interface.
+func (e *FixError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return "fixchain: unknown error"
+}
@@ -1,6 +1,8 @@
 package fixchain
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"sync"
@@ -10,29 +12,96 @@ import (
 	"github.com/google/certificate-transparency-go/x509"
 )
 
+// defaultWorkers is the number of goroutines started by NewFixer when
+// WithWorkers is not passed.
+const defaultWorkers = 100
+
 // Fixer contains methods to fix certificate chains and properties to store
 // information about each attempt that is made to fix a certificate chain.
 type Fixer struct {
 	toFix  chan *toFix
 	chains chan []*x509.Certificate // Chains successfully fixed by the fixer
-	active uint32
-	// Counters may not be entirely accurate due to non-atomicity
-	reconstructed    uint
-	notReconstructed uint
-	fixed            uint
-	notFixed         uint
-	skipped          uint
-	alreadyDone      uint
+	active atomic.Uint32
+	// Counters are updated from every fixServer goroutine; use Stats() for a
+	// snapshot.
+	reconstructed    atomic.Uint64
+	notReconstructed atomic.Uint64
+	fixed            atomic.Uint64
+	notFixed         atomic.Uint64
+	skipped          atomic.Uint64
+
+	wg         sync.WaitGroup
+	errors     chan *FixError
+	cache      *urlCache
+	done       *lockedMap
+	ctx        context.Context
+	workers    int
+	logStatsOn bool
+	recorder   metricsRecorder
+}
+
+// FixerOption configures optional behaviour of a Fixer created by NewFixer.
+type FixerOption func(*Fixer)
+
+// WithWorkers sets the number of goroutines used to fix chains concurrently.
+// The default is 100.
+func WithWorkers(n int) FixerOption {
+	return func(f *Fixer) { f.workers = n }
+}
+
+// WithContext binds the Fixer to ctx. Cancelling ctx aborts in-flight
+// intermediate-certificate fetches and causes workers to stop picking up new
+// work. The default is context.Background(), i.e. no cancellation.
+func WithContext(ctx context.Context) FixerOption {
+	return func(f *Fixer) { f.ctx = ctx }
+}
+
+// WithFetchTimeout bounds how long a single HTTP attempt to fetch an
+// intermediate certificate may take. An attempt that times out is retried
+// under the Fixer's RetryPolicy like any other timeout. The default, zero,
+// means no per-attempt timeout beyond the Fixer's context.
+func WithFetchTimeout(d time.Duration) FixerOption {
+	return func(f *Fixer) { f.cache.fetchTimeout = d }
+}
+
+// WithLogStats controls the legacy behaviour of logging a one-line summary
+// of the Fixer's counters to the standard logger once a second. It is on by
+// default, to match NewFixer's historical behaviour; pass WithLogStats(false)
+// to quiet it, e.g. when using WithCollector for structured metrics instead.
+func WithLogStats(enabled bool) FixerOption {
+	return func(f *Fixer) { f.logStatsOn = enabled }
+}
+
+// WithCollector attaches c to the Fixer so that per-chain fix latency,
+// errors, and fetch retries are recorded against it as the Fixer runs. It
+// also binds c to the Fixer, so that c exposes this Fixer's counters.
+func WithCollector(c *Collector) FixerOption {
+	return func(f *Fixer) {
+		c.fixer = f
+		f.recorder = c
+		f.cache.recorder = c
+	}
+}
 
-	wg     sync.WaitGroup
-	errors chan *FixError
-	cache  *urlCache
-	done   *lockedMap
+// WithPerHostConcurrency bounds how many intermediate-certificate fetches
+// the Fixer will have in flight to any single host at once. The default is
+// 4, which keeps a single misbehaving CA from receiving hundreds of
+// parallel requests just because many chains reference it.
+func WithPerHostConcurrency(n int) FixerOption {
+	return func(f *Fixer) { f.cache.perHostConcurrency = n }
+}
+
+// WithRetryPolicy overrides the backoff and retry behaviour used when an
+// intermediate fetch fails with a retryable error (429, 5xx, or a timeout).
+func WithRetryPolicy(p RetryPolicy) FixerOption {
+	return func(f *Fixer) { f.cache.retry = p }
 }
 
 // QueueChain adds the given cert and chain to the queue to be fixed by the
-// fixer, with respect to the given roots
-func (f *Fixer) QueueChain(cert *x509.Certificate, d *DedupedChain, roots *x509.CertPool) {
+// fixer, with respect to the given roots. It returns an error, rather than
+// blocking forever, if the Fixer's context is done before the chain can be
+// queued.
+func (f *Fixer) QueueChain(cert *x509.Certificate, d *DedupedChain, roots *x509.CertPool) error {
 	intermediates := x509.NewCertPool()
 	for _, c := range d.certs {
 		intermediates.AddCert(c)
@@ -45,10 +114,16 @@ func (f *Fixer) QueueChain(cert *x509.Certificate, d *DedupedChain, roots *x509.
 		KeyUsages:         []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
 	}
 
-	f.toFix <- &toFix{cert: cert, chain: d, opts: &opts, fixer: f}
+	select {
+	case f.toFix <- &toFix{cert: cert, chain: d, opts: &opts, fixer: f}:
+		return nil
+	case <-f.ctx.Done():
+		return fmt.Errorf("fixchain: QueueChain: %v", f.ctx.Err())
+	}
 }
 
-// Wait for all the fixers to finish
+// Wait for all the fixers to finish, which happens once the queue is closed
+// or the Fixer's context is cancelled, whichever comes first.
 func (f *Fixer) Wait() {
 	close(f.toFix)
 	f.wg.Wait()
@@ -57,22 +132,45 @@ func (f *Fixer) Wait() {
 func (f *Fixer) fixServer() {
 	defer f.wg.Done()
 
-	for fix := range f.toFix {
-		atomic.AddUint32(&f.active, 1)
-		chains, ferr := fix.handleChain()
-		if ferr != nil {
-			f.errors <- ferr
-		} else {
-			for _, chain := range chains {
-				f.chains <- chain
+	for {
+		select {
+		case fix, ok := <-f.toFix:
+			if !ok {
+				return
+			}
+			f.active.Add(1)
+			start := time.Now()
+			chains, ferr := fix.handleChain(f.ctx)
+			f.active.Add(^uint32(0))
+			if f.recorder != nil {
+				f.recorder.observeFixLatency(time.Since(start))
 			}
+			if ferr != nil {
+				if f.recorder != nil {
+					f.recorder.incError(ferr.Type)
+				}
+				select {
+				case f.errors <- ferr:
+				case <-f.ctx.Done():
+					return
+				}
+			} else {
+				for _, chain := range chains {
+					select {
+					case f.chains <- chain:
+					case <-f.ctx.Done():
+						return
+					}
+				}
+			}
+		case <-f.ctx.Done():
+			return
 		}
-		atomic.AddUint32(&f.active, ^uint32(0))
 	}
 }
 
 func (f *Fixer) newFixServerPool() {
-	for i := 0; i < 100; i++ {
+	for i := 0; i < f.workers; i++ {
 		f.wg.Add(1)
 		go f.fixServer()
 	}
@@ -81,29 +179,61 @@ func (f *Fixer) newFixServerPool() {
 func (f *Fixer) logStats() {
 	t := time.NewTicker(time.Second)
 	go func() {
-		for _ = range t.C {
-			log.Printf("fixers: %d active, "+
-				"%d reconstructed, %d not reconstructed, "+
-				"%d fixed, %d not fixed, %d skipped, %d already done",
-				f.active, f.reconstructed, f.notReconstructed,
-				f.fixed, f.notFixed, f.skipped, f.alreadyDone)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				s := f.Stats()
+				log.Printf("fixers: %d active, "+
+					"%d reconstructed, %d not reconstructed, "+
+					"%d fixed, %d not fixed, %d skipped",
+					s.Active, s.Reconstructed, s.NotReconstructed,
+					s.Fixed, s.NotFixed, s.Skipped)
+			case <-f.ctx.Done():
+				return
+			}
 		}
 	}()
 }
 
-// NewFixer creates a new fixer and starts up a pool of workers.  Errors are
-// pushed to the errors channel, and fixed chains are pushed to the chains
-// channel.
-func NewFixer(chains chan []*x509.Certificate, errors chan *FixError, client *http.Client) *Fixer {
+func newFixer(cache *urlCache, chains chan []*x509.Certificate, errors chan *FixError, opts ...FixerOption) *Fixer {
 	f := &Fixer{
-		toFix:  make(chan *toFix),
-		chains: chains,
-		errors: errors,
-		cache:  newURLCache(client),
-		done:   newLockedMap(),
+		toFix:      make(chan *toFix),
+		chains:     chains,
+		errors:     errors,
+		cache:      cache,
+		done:       newLockedMap(),
+		workers:    defaultWorkers,
+		logStatsOn: true,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if f.ctx == nil {
+		f.ctx = context.Background()
 	}
 
 	f.newFixServerPool()
-	f.logStats()
+	if f.logStatsOn {
+		f.logStats()
+	}
 	return f
 }
+
+// NewFixer creates a new fixer and starts up a pool of workers. Errors are
+// pushed to the errors channel, and fixed chains are pushed to the chains
+// channel. By default it starts 100 workers bound to context.Background(),
+// and keeps fetched intermediates in memory only; pass FixerOptions to
+// override the former, or use NewFixerWithCache to override the latter.
+func NewFixer(chains chan []*x509.Certificate, errors chan *FixError, client *http.Client, opts ...FixerOption) *Fixer {
+	return newFixer(newURLCache(client), chains, errors, opts...)
+}
+
+// NewFixerWithCache is like NewFixer, but fetched intermediates are looked
+// up in and stored back to cache instead of being kept in memory only. This
+// lets operators resubmitting large historical corpora, or running several
+// fixers side by side, preserve fetched intermediates across process
+// restarts or share them between fixers.
+func NewFixerWithCache(cache CertCache, chains chan []*x509.Certificate, errors chan *FixError, client *http.Client, opts ...FixerOption) *Fixer {
+	return newFixer(newURLCacheWithBacking(client, cache), chains, errors, opts...)
+}
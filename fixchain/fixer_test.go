@@ -0,0 +1,66 @@
+package fixchain
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/certificate-transparency-go/x509"
+)
+
+// TestQueueChainRespectsContextCancellation checks that QueueChain does not
+// block forever past context cancellation when no worker is available to
+// receive the chain.
+func TestQueueChainRespectsContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	f := NewFixer(make(chan []*x509.Certificate, 1), make(chan *FixError, 1), http.DefaultClient,
+		WithContext(ctx), WithWorkers(0), WithLogStats(false))
+
+	tc := newTestChain(t, "")
+	err := f.QueueChain(tc.leafCert, NewDedupedChain(nil), tc.roots())
+	if err == nil {
+		t.Fatal("QueueChain returned nil error after context cancellation, want an error")
+	}
+}
+
+// TestWaitReturnsAfterContextCancellation checks that cancelling a Fixer's
+// context promptly unblocks a worker stuck on an in-flight fetch, so Wait
+// returns instead of hanging. This guards against a prior bug where
+// fixServer could block forever sending to f.errors/f.chains past
+// cancellation.
+func TestWaitReturnsAfterContextCancellation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tc := newTestChain(t, srv.URL)
+	f := NewFixer(make(chan []*x509.Certificate, 1), make(chan *FixError), srv.Client(),
+		WithContext(ctx), WithWorkers(1), WithLogStats(false))
+
+	go f.QueueChain(tc.leafCert, NewDedupedChain(nil), tc.roots())
+	time.Sleep(50 * time.Millisecond) // let the worker start fetching
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		f.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Wait did not return within 2s of context cancellation")
+	}
+}
@@ -0,0 +1,23 @@
+package fixchain
+
+import "sync"
+
+// lockedMap is a concurrency-safe set of strings, used to track chains that
+// have already been handled so repeat submissions can be skipped.
+type lockedMap struct {
+	mu sync.Mutex
+	m  map[string]bool
+}
+
+func newLockedMap() *lockedMap {
+	return &lockedMap{m: make(map[string]bool)}
+}
+
+// Set marks key as present and reports whether it was already present.
+func (l *lockedMap) Set(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	present := l.m[key]
+	l.m[key] = true
+	return present
+}
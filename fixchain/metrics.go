@@ -0,0 +1,140 @@
+package fixchain
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Stats is a point-in-time snapshot of a Fixer's progress counters.
+type Stats struct {
+	Active           uint32
+	Reconstructed    uint64
+	NotReconstructed uint64
+	Fixed            uint64
+	NotFixed         uint64
+	Skipped          uint64
+}
+
+// Stats returns a snapshot of the Fixer's current counters. Each counter is
+// read atomically, but the counters are not all read at the same instant.
+func (f *Fixer) Stats() Stats {
+	return Stats{
+		Active:           f.active.Load(),
+		Reconstructed:    f.reconstructed.Load(),
+		NotReconstructed: f.notReconstructed.Load(),
+		Fixed:            f.fixed.Load(),
+		NotFixed:         f.notFixed.Load(),
+		Skipped:          f.skipped.Load(),
+	}
+}
+
+// metricsRecorder receives per-chain and per-fetch events as a Fixer runs. A
+// Fixer with no recorder attached skips this bookkeeping entirely.
+type metricsRecorder interface {
+	observeFixLatency(d time.Duration)
+	incError(t ErrorType)
+	incRetry(host string)
+}
+
+// Collector adapts a Fixer's counters and per-chain events to Prometheus
+// collection. Register it with a prometheus.Registerer to scrape it, and
+// pass it to NewFixer via WithCollector to start recording.
+type Collector struct {
+	fixer *Fixer
+
+	reconstructed    *prometheus.Desc
+	notReconstructed *prometheus.Desc
+	fixed            *prometheus.Desc
+	notFixed         *prometheus.Desc
+	skipped          *prometheus.Desc
+	active           *prometheus.Desc
+
+	errorsByType  *prometheus.CounterVec
+	retriesByHost *prometheus.CounterVec
+	fixLatency    prometheus.Histogram
+}
+
+// NewCollector returns a Collector exposing f's progress counters. f may be
+// nil if the Collector is then passed to NewFixer via WithCollector, which
+// binds it to the new Fixer.
+func NewCollector(f *Fixer) *Collector {
+	return &Collector{
+		fixer: f,
+		reconstructed: prometheus.NewDesc(
+			"fixchain_chains_reconstructed_total",
+			"Chains successfully verified using only the supplied intermediates.",
+			nil, nil),
+		notReconstructed: prometheus.NewDesc(
+			"fixchain_chains_not_reconstructed_total",
+			"Chains that could not be verified using only the supplied intermediates.",
+			nil, nil),
+		fixed: prometheus.NewDesc(
+			"fixchain_chains_fixed_total",
+			"Chains successfully fixed by fetching missing intermediates.",
+			nil, nil),
+		notFixed: prometheus.NewDesc(
+			"fixchain_chains_not_fixed_total",
+			"Chains that could not be fixed.",
+			nil, nil),
+		skipped: prometheus.NewDesc(
+			"fixchain_chains_skipped_total",
+			"Chains skipped because they were already handled.",
+			nil, nil),
+		active: prometheus.NewDesc(
+			"fixchain_active_workers",
+			"Number of fixer workers currently processing a chain.",
+			nil, nil),
+		errorsByType: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fixchain_errors_total",
+			Help: "Errors encountered while fixing chains, by type.",
+		}, []string{"type"}),
+		retriesByHost: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fixchain_retries_total",
+			Help: "Retried intermediate-certificate fetches, by host.",
+		}, []string{"host"}),
+		fixLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "fixchain_fix_latency_seconds",
+			Help: "Time taken to process a single chain, successful or not.",
+		}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.reconstructed
+	ch <- c.notReconstructed
+	ch <- c.fixed
+	ch <- c.notFixed
+	ch <- c.skipped
+	ch <- c.active
+	c.errorsByType.Describe(ch)
+	c.retriesByHost.Describe(ch)
+	c.fixLatency.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	s := c.fixer.Stats()
+	ch <- prometheus.MustNewConstMetric(c.reconstructed, prometheus.CounterValue, float64(s.Reconstructed))
+	ch <- prometheus.MustNewConstMetric(c.notReconstructed, prometheus.CounterValue, float64(s.NotReconstructed))
+	ch <- prometheus.MustNewConstMetric(c.fixed, prometheus.CounterValue, float64(s.Fixed))
+	ch <- prometheus.MustNewConstMetric(c.notFixed, prometheus.CounterValue, float64(s.NotFixed))
+	ch <- prometheus.MustNewConstMetric(c.skipped, prometheus.CounterValue, float64(s.Skipped))
+	ch <- prometheus.MustNewConstMetric(c.active, prometheus.GaugeValue, float64(s.Active))
+	c.errorsByType.Collect(ch)
+	c.retriesByHost.Collect(ch)
+	c.fixLatency.Collect(ch)
+}
+
+func (c *Collector) observeFixLatency(d time.Duration) {
+	c.fixLatency.Observe(d.Seconds())
+}
+
+func (c *Collector) incError(t ErrorType) {
+	c.errorsByType.WithLabelValues(t.String()).Inc()
+}
+
+func (c *Collector) incRetry(host string) {
+	c.retriesByHost.WithLabelValues(host).Inc()
+}
@@ -0,0 +1,70 @@
+package fixchain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/google/certificate-transparency-go/x509"
+)
+
+// toFix holds a single chain submission waiting to be fixed.
+type toFix struct {
+	cert  *x509.Certificate
+	chain *DedupedChain
+	opts  *x509.VerifyOptions
+	fixer *Fixer
+}
+
+// handleChain attempts to build one or more valid chains from cert through
+// the supplied intermediates and roots, fetching the intermediates named in
+// cert's own AIA extension if those are not enough; the AIA extensions of
+// fetched certificates are not followed. It returns the reconstructed
+// chains, or a FixError describing why none could be built.
+// ctx governs the HTTP fetches issued to retrieve missing intermediates.
+// A cert the Fixer has already handled is skipped, whatever chain it comes
+// with, returning (nil, nil); all outcomes update the Fixer's counters (see
+// Stats).
+func (f *toFix) handleChain(ctx context.Context) ([][]*x509.Certificate, *FixError) {
+	sum := sha256.Sum256(f.cert.Raw)
+	if f.fixer.done.Set(hex.EncodeToString(sum[:])) {
+		f.fixer.skipped.Add(1)
+		return nil, nil
+	}
+
+	if chains, err := f.cert.Verify(*f.opts); err == nil {
+		f.fixer.reconstructed.Add(1)
+		return chains, nil
+	}
+	f.fixer.notReconstructed.Add(1)
+
+	for _, url := range f.cert.IssuingCertificateURL {
+		select {
+		case <-ctx.Done():
+			f.fixer.notFixed.Add(1)
+			return nil, &FixError{Type: CannotFetchURL, Cert: f.cert, Chain: f.chain, URL: url, Err: ctx.Err()}
+		default:
+		}
+
+		der, err := f.fixer.cache.getURL(ctx, url)
+		if err != nil {
+			f.fixer.notFixed.Add(1)
+			return nil, &FixError{Type: CannotFetchURL, Cert: f.cert, Chain: f.chain, URL: url, Err: err}
+		}
+
+		issuer, err := x509.ParseCertificate(der)
+		if err != nil {
+			f.fixer.notFixed.Add(1)
+			return nil, &FixError{Type: ParseFailure, Cert: f.cert, Chain: f.chain, URL: url, Err: err}
+		}
+		f.opts.Intermediates.AddCert(issuer)
+
+		if chains, err := f.cert.Verify(*f.opts); err == nil {
+			f.fixer.fixed.Add(1)
+			return chains, nil
+		}
+	}
+
+	f.fixer.notFixed.Add(1)
+	return nil, &FixError{Type: FixFailed, Cert: f.cert, Chain: f.chain}
+}
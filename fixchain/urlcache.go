@@ -0,0 +1,257 @@
+package fixchain
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// defaultPerHostConcurrency bounds how many intermediate-certificate
+// fetches may be in flight to a single host at once, absent a
+// WithPerHostConcurrency override.
+const defaultPerHostConcurrency = 4
+
+// RetryPolicy controls how urlCache retries a fetch that failed with a
+// retryable error (HTTP 429, 5xx, or a timeout).
+type RetryPolicy struct {
+	// MaxRetries is the number of additional attempts made after the first.
+	MaxRetries int
+	// BaseDelay is the backoff delay before the first retry. It doubles
+	// after each subsequent retry, capped at MaxDelay, and is jittered by
+	// up to 50% to avoid synchronised retries against the same host.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff delay.
+	MaxDelay time.Duration
+}
+
+// defaultRetryPolicy is used when no WithRetryPolicy option is supplied.
+var defaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	BaseDelay:  200 * time.Millisecond,
+	MaxDelay:   5 * time.Second,
+}
+
+// CertCache stores the result of fetching an intermediate certificate by
+// the URL it was fetched from, so that repeated fetches of the same URL,
+// potentially across process restarts or between fixers, don't have to hit
+// the network again.
+type CertCache interface {
+	// Get returns the cached DER bytes for url, and whether a cached result
+	// exists at all. A cached negative result (a previous fetch of url
+	// failed) is reported as (nil, true); an absent entry is (nil, false).
+	Get(url string) ([]byte, bool)
+	// Put records the outcome of fetching url: der on success, or nil to
+	// record a negative result.
+	Put(url string, der []byte)
+}
+
+// urlCache fetches intermediate certificates over HTTP, coalescing
+// concurrent fetches of the same URL made through it and consulting a
+// pluggable CertCache before going to the network.
+type urlCache struct {
+	client             *http.Client
+	fetchTimeout       time.Duration
+	backing            CertCache
+	perHostConcurrency int
+	retry              RetryPolicy
+	recorder           metricsRecorder
+
+	mu       sync.Mutex
+	inFlight map[string]*urlEntry
+	perHost  map[string]*semaphore.Weighted
+}
+
+// urlEntry holds the (possibly still in-flight) result of fetching a URL.
+type urlEntry struct {
+	once sync.Once
+	der  []byte
+	err  error
+}
+
+// newURLCache creates a urlCache that fetches over client and keeps fetched
+// intermediates in memory only, for the lifetime of the process.
+func newURLCache(client *http.Client) *urlCache {
+	return newURLCacheWithBacking(client, NewMemCertCache(0))
+}
+
+// newURLCacheWithBacking creates a urlCache that fetches over client,
+// consulting backing before issuing an HTTP GET and storing the outcome
+// back into backing afterwards.
+func newURLCacheWithBacking(client *http.Client, backing CertCache) *urlCache {
+	return &urlCache{
+		client:   client,
+		backing:  backing,
+		retry:    defaultRetryPolicy,
+		inFlight: make(map[string]*urlEntry),
+		perHost:  make(map[string]*semaphore.Weighted),
+	}
+}
+
+// getURL returns the bytes served at url, consulting the backing CertCache
+// and falling back to an HTTP fetch on a cache miss. Within a single
+// urlCache, concurrent callers asking for the same url share one fetch; if
+// the backing CertCache is a fetchCoalescer (e.g. SingleflightCertCache),
+// that sharing extends across every urlCache pointed at the same backing
+// cache. The fetch is aborted if ctx is done; if fetchTimeout is set, each
+// HTTP attempt is cut off once it elapses and counts as a retryable failure.
+func (u *urlCache) getURL(ctx context.Context, url string) ([]byte, error) {
+	u.mu.Lock()
+	e, ok := u.inFlight[url]
+	if !ok {
+		e = &urlEntry{}
+		u.inFlight[url] = e
+	}
+	u.mu.Unlock()
+
+	e.once.Do(func() {
+		if rc, ok := u.backing.(fetchCoalescer); ok {
+			e.der, e.err = rc.resolve(url, func() ([]byte, error) { return u.fetch(ctx, url) })
+			return
+		}
+
+		if der, found := u.backing.Get(url); found {
+			if der == nil {
+				e.err = fmt.Errorf("fixchain: cached negative result for %q", url)
+			} else {
+				e.der = der
+			}
+			return
+		}
+
+		e.der, e.err = u.fetch(ctx, url)
+		if e.err == nil {
+			u.backing.Put(url, e.der)
+		} else {
+			u.backing.Put(url, nil)
+		}
+	})
+
+	// Only coalesce fetches that are in flight; later lookups go back to
+	// the backing CertCache so that its expiry rules apply.
+	u.mu.Lock()
+	if u.inFlight[url] == e {
+		delete(u.inFlight, url)
+	}
+	u.mu.Unlock()
+	return e.der, e.err
+}
+
+// fetch fetches url, bounding concurrency to other fetches of the same
+// host and retrying retryable failures with exponential backoff and
+// jitter, per u.retry.
+func (u *urlCache) fetch(ctx context.Context, url string) ([]byte, error) {
+	sem := u.hostSemaphore(url)
+	if err := sem.Acquire(ctx, 1); err != nil {
+		return nil, fmt.Errorf("fixchain: waiting for a free slot to fetch %q: %v", url, err)
+	}
+	defer sem.Release(1)
+
+	var lastErr error
+	for attempt := 0; attempt <= u.retry.MaxRetries; attempt++ {
+		if attempt > 0 {
+			if u.recorder != nil {
+				u.recorder.incRetry(hostOf(url))
+			}
+			select {
+			case <-time.After(backoffDelay(u.retry, attempt)):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+
+		der, retryable, err := u.fetchOnce(ctx, url)
+		if err == nil {
+			return der, nil
+		}
+		lastErr = err
+		if !retryable {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// fetchOnce issues a single HTTP GET for url, reporting whether the error
+// (if any) is worth retrying.
+func (u *urlCache) fetchOnce(ctx context.Context, url string) (der []byte, retryable bool, err error) {
+	if u.fetchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, u.fetchTimeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("fixchain: building request for %q: %v", url, err)
+	}
+	req = req.WithContext(ctx)
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return nil, true, fmt.Errorf("fixchain: fetching %q: %v", url, err)
+		}
+		return nil, false, fmt.Errorf("fixchain: fetching %q: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		der, err := ioutil.ReadAll(resp.Body)
+		return der, false, err
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return nil, true, fmt.Errorf("fixchain: fetching %q: got status %d", url, resp.StatusCode)
+	default:
+		return nil, false, fmt.Errorf("fixchain: fetching %q: got status %d", url, resp.StatusCode)
+	}
+}
+
+// hostSemaphore returns the semaphore limiting concurrent fetches to the
+// host that url points at, creating it on first use.
+func (u *urlCache) hostSemaphore(url string) *semaphore.Weighted {
+	host := hostOf(url)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	sem, ok := u.perHost[host]
+	if !ok {
+		n := u.perHostConcurrency
+		if n <= 0 {
+			n = defaultPerHostConcurrency
+		}
+		sem = semaphore.NewWeighted(int64(n))
+		u.perHost[host] = sem
+	}
+	return sem
+}
+
+// hostOf returns the host component of rawurl, or rawurl itself if it
+// cannot be parsed as a URL.
+func hostOf(rawurl string) string {
+	u, err := neturl.Parse(rawurl)
+	if err != nil {
+		return rawurl
+	}
+	return u.Host
+}
+
+// backoffDelay returns how long to wait before the given retry attempt
+// (1-indexed) under policy p, including jitter.
+func backoffDelay(p RetryPolicy, attempt int) time.Duration {
+	d := p.BaseDelay << uint(attempt-1)
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d)/2+1))
+}
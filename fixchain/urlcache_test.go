@@ -0,0 +1,98 @@
+package fixchain
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestFetchRetriesRetryableStatus checks that a 503 is retried and that the
+// eventual success is returned once the server recovers.
+func TestFetchRetriesRetryableStatus(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("der-bytes"))
+	}))
+	defer srv.Close()
+
+	u := newURLCacheWithBacking(srv.Client(), NewMemCertCache(0))
+	u.retry = RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	der, err := u.fetch(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("fetch returned error %v, want nil", err)
+	}
+	if string(der) != "der-bytes" {
+		t.Fatalf("fetch returned %q, want %q", der, "der-bytes")
+	}
+	if got := requests.Load(); got != 3 {
+		t.Fatalf("server saw %d requests, want 3", got)
+	}
+}
+
+// TestFetchDoesNotRetryNonRetryableStatus checks that a 404 is not retried.
+func TestFetchDoesNotRetryNonRetryableStatus(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	u := newURLCacheWithBacking(srv.Client(), NewMemCertCache(0))
+	u.retry = RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	if _, err := u.fetch(context.Background(), srv.URL); err == nil {
+		t.Fatal("fetch returned nil error for a 404, want an error")
+	}
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("server saw %d requests, want 1 (no retries)", got)
+	}
+}
+
+// TestPerHostConcurrencyBound checks that fetch never lets more than
+// perHostConcurrency requests to the same host run at once.
+func TestPerHostConcurrencyBound(t *testing.T) {
+	const limit = 2
+	var current, max atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := current.Add(1)
+		for {
+			old := max.Load()
+			if n <= old || max.CompareAndSwap(old, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		current.Add(-1)
+	}))
+	defer srv.Close()
+
+	u := newURLCacheWithBacking(srv.Client(), NewMemCertCache(0))
+	u.perHostConcurrency = limit
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			url := srv.URL + "/" + string(rune('a'+i))
+			if _, err := u.fetch(context.Background(), url); err != nil {
+				t.Errorf("fetch(%q): %v", url, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := max.Load(); got > limit {
+		t.Fatalf("max observed concurrency was %d, want <= %d", got, limit)
+	}
+}